@@ -0,0 +1,85 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"crypto/tls"
+	"strings"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/pkg/transport"
+)
+
+// newEtcdClient creates a new clientv3.Client based on the passed backend
+// config. Host may hold a single endpoint or a comma-separated list of
+// endpoints, in which case the client will be configured to connect to, and
+// fail over between, all of them. If the backend config carries client
+// certificate information, the client is configured for mutual TLS.
+func newEtcdClient(backendConfig *BackendConfig) (*clientv3.Client, error) {
+	endpoints := strings.Split(backendConfig.Host, ",")
+
+	dialTimeout := backendConfig.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	keepAliveTime := backendConfig.DialKeepAliveTime
+	if keepAliveTime == 0 {
+		keepAliveTime = defaultKeepAliveTime
+	}
+
+	keepAliveTimeout := backendConfig.DialKeepAliveTimeout
+	if keepAliveTimeout == 0 {
+		keepAliveTimeout = defaultKeepAliveTimeout
+	}
+
+	autoSyncInterval := backendConfig.AutoSyncInterval
+	if autoSyncInterval == 0 {
+		autoSyncInterval = defaultAutoSyncInterval
+	} else if autoSyncInterval < 0 {
+		autoSyncInterval = 0
+	}
+
+	maxSendMsgSize := backendConfig.MaxCallSendMsgSize
+	if maxSendMsgSize == 0 {
+		maxSendMsgSize = defaultMaxMsgSize
+	}
+
+	maxRecvMsgSize := backendConfig.MaxCallRecvMsgSize
+	if maxRecvMsgSize == 0 {
+		maxRecvMsgSize = defaultMaxMsgSize
+	}
+
+	clientCfg := clientv3.Config{
+		Context:              backendConfig.Ctx,
+		Endpoints:            endpoints,
+		Username:             backendConfig.User,
+		Password:             backendConfig.Pass,
+		DialTimeout:          dialTimeout,
+		DialKeepAliveTime:    keepAliveTime,
+		DialKeepAliveTimeout: keepAliveTimeout,
+		AutoSyncInterval:     autoSyncInterval,
+		MaxCallSendMsgSize:   maxSendMsgSize,
+		MaxCallRecvMsgSize:   maxRecvMsgSize,
+	}
+
+	if backendConfig.CertFile != "" || backendConfig.CAFile != "" {
+		tlsInfo := transport.TLSInfo{
+			CertFile:      backendConfig.CertFile,
+			KeyFile:       backendConfig.KeyFile,
+			TrustedCAFile: backendConfig.CAFile,
+			ServerName:    backendConfig.ServerName,
+		}
+
+		tlsConfig, err := tlsInfo.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		clientCfg.TLS = tlsConfig
+	} else if backendConfig.InsecureSkipVerify {
+		clientCfg.TLS = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return clientv3.New(clientCfg)
+}
@@ -0,0 +1,219 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/embed"
+)
+
+// clusterNode holds the state of a single member of an embedded etcd
+// cluster, so that it can be stopped and restarted independently of its
+// peers.
+type clusterNode struct {
+	name      string
+	dir       string
+	clientURL string
+	peerURL   string
+	etcd      *embed.Etcd
+}
+
+// EtcdCluster represents a multi-node embedded etcd cluster started by
+// NewEmbeddedEtcdCluster. It allows tests to stop and restart individual
+// nodes in order to simulate leader failover and quorum loss.
+type EtcdCluster struct {
+	nodes          []*clusterNode
+	initialCluster string
+}
+
+// config builds the embed.Config used to (re)start the node at the given
+// index. newCluster indicates whether the node is joining a brand new
+// cluster (true) or rejoining an existing one (false).
+func (c *EtcdCluster) config(idx int, newCluster bool) *embed.Config {
+	node := c.nodes[idx]
+
+	cfg := embed.NewConfig()
+	cfg.Dir = node.dir
+	cfg.Name = node.name
+
+	// To ensure that we can submit large transactions.
+	cfg.MaxTxnOps = defaultMaxTxnOps
+	cfg.MaxRequestBytes = defaultMaxMsgSize
+
+	cfg.LCUrls = []url.URL{{Scheme: "http", Host: node.clientURL}}
+	cfg.LPUrls = []url.URL{{Scheme: "http", Host: node.peerURL}}
+	cfg.ACUrls = cfg.LCUrls
+	cfg.APUrls = cfg.LPUrls
+
+	cfg.InitialCluster = c.initialCluster
+	if newCluster {
+		cfg.ClusterState = embed.ClusterStateFlagNew
+	} else {
+		cfg.ClusterState = embed.ClusterStateFlagExisting
+	}
+
+	return cfg
+}
+
+// launchNode starts the node at the given index without waiting for it to
+// become ready. For a brand new cluster, nodes must all be launched before
+// any of them can be awaited, since a lone member can't reach quorum and
+// elect a leader on its own.
+func (c *EtcdCluster) launchNode(idx int, newCluster bool) error {
+	etcd, err := embed.StartEtcd(c.config(idx, newCluster))
+	if err != nil {
+		return err
+	}
+
+	c.nodes[idx].etcd = etcd
+
+	return nil
+}
+
+// awaitNode blocks until the node at the given index is ready to serve or
+// deadline elapses.
+func (c *EtcdCluster) awaitNode(idx int, deadline time.Time) error {
+	node := c.nodes[idx]
+
+	select {
+	case <-node.etcd.Server.ReadyNotify():
+	case <-time.After(time.Until(deadline)):
+		node.etcd.Close()
+		node.etcd = nil
+		return fmt.Errorf("etcd node %v failed to start after: %v",
+			node.name, readyTimeout)
+	}
+
+	return nil
+}
+
+// startNode starts (or restarts) the node at the given index and blocks
+// until it is ready to serve or readyTimeout elapses.
+func (c *EtcdCluster) startNode(idx int, newCluster bool) error {
+	if err := c.launchNode(idx, newCluster); err != nil {
+		return err
+	}
+
+	return c.awaitNode(idx, time.Now().Add(readyTimeout))
+}
+
+// StopNode stops the node at the given index, simulating that peer going
+// offline (e.g. to test leader failover or quorum loss). The node can later
+// be brought back with RestartNode.
+func (c *EtcdCluster) StopNode(idx int) error {
+	if idx < 0 || idx >= len(c.nodes) {
+		return fmt.Errorf("invalid node index: %v", idx)
+	}
+
+	node := c.nodes[idx]
+	if node.etcd == nil {
+		return fmt.Errorf("node %v is not running", node.name)
+	}
+
+	node.etcd.Close()
+	node.etcd = nil
+
+	return nil
+}
+
+// RestartNode restarts a previously stopped node at the given index,
+// rejoining the existing cluster.
+func (c *EtcdCluster) RestartNode(idx int) error {
+	if idx < 0 || idx >= len(c.nodes) {
+		return fmt.Errorf("invalid node index: %v", idx)
+	}
+
+	if c.nodes[idx].etcd != nil {
+		return fmt.Errorf("node %v is already running",
+			c.nodes[idx].name)
+	}
+
+	return c.startNode(idx, false)
+}
+
+// Stop stops every node in the cluster.
+func (c *EtcdCluster) Stop() {
+	for idx, node := range c.nodes {
+		if node.etcd != nil {
+			c.nodes[idx].etcd.Close()
+			c.nodes[idx].etcd = nil
+		}
+	}
+}
+
+// NewEmbeddedEtcdCluster creates a multi-node embedded etcd cluster for
+// testing, under the given path, consisting of size peers. It returns a
+// BackendConfig whose Host is a comma-separated list of all client
+// endpoints, the EtcdCluster handle used to stop/restart individual nodes,
+// and an error, if any. The whole cluster can be torn down by calling
+// EtcdCluster.Stop.
+func NewEmbeddedEtcdCluster(path string, size int) (*BackendConfig,
+	*EtcdCluster, error) {
+
+	if size < 1 {
+		return nil, nil, fmt.Errorf("cluster size must be positive, "+
+			"got: %v", size)
+	}
+
+	cluster := &EtcdCluster{
+		nodes: make([]*clusterNode, size),
+	}
+
+	initialCluster := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		peerURL := fmt.Sprintf("127.0.0.1:%d", getFreePort())
+
+		cluster.nodes[i] = &clusterNode{
+			name: name,
+			dir: fmt.Sprintf("%s/%s", strings.TrimRight(
+				path, "/"), name),
+			clientURL: fmt.Sprintf("127.0.0.1:%d", getFreePort()),
+			peerURL:   peerURL,
+		}
+
+		initialCluster = append(initialCluster, fmt.Sprintf(
+			"%s=http://%s", name, peerURL))
+	}
+	cluster.initialCluster = strings.Join(initialCluster, ",")
+
+	// Launch every node before waiting on any of them: a freshly
+	// bootstrapping member can't reach quorum or elect a leader with
+	// only itself online, so starting and awaiting one node at a time
+	// would always time out the first node for any size > 1.
+	for i := range cluster.nodes {
+		if err := cluster.launchNode(i, true); err != nil {
+			cluster.Stop()
+			return nil, nil, err
+		}
+	}
+
+	deadline := time.Now().Add(readyTimeout)
+	for i := range cluster.nodes {
+		if err := cluster.awaitNode(i, deadline); err != nil {
+			cluster.Stop()
+			return nil, nil, err
+		}
+	}
+
+	clientURLs := make([]string, 0, size)
+	for _, node := range cluster.nodes {
+		clientURLs = append(clientURLs,
+			"http://"+node.clientURL)
+	}
+
+	connConfig := &BackendConfig{
+		Ctx:                context.Background(),
+		Host:               strings.Join(clientURLs, ","),
+		User:               "user",
+		Pass:               "pass",
+		InsecureSkipVerify: true,
+	}
+
+	return connConfig, cluster, nil
+}
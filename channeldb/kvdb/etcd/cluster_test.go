@@ -0,0 +1,116 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+)
+
+// TestEmbeddedEtcdClusterSurvivesNodeLoss starts a 3-node embedded etcd
+// cluster, confirms a write/read round trip against it, stops a single
+// (minority) node to simulate losing a peer, confirms the remaining
+// majority still serves reads and writes, and finally restarts the node and
+// confirms it rejoins and catches up on the key written while it was down.
+func TestEmbeddedEtcdClusterSurvivesNodeLoss(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "etcd-cluster-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	backendConfig, cluster, err := NewEmbeddedEtcdCluster(tempDir, 3)
+	if err != nil {
+		t.Fatalf("unable to start etcd cluster: %v", err)
+	}
+	defer cluster.Stop()
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   strings.Split(backendConfig.Host, ","),
+		DialTimeout: defaultDialTimeout,
+	})
+	if err != nil {
+		t.Fatalf("unable to create etcd client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	if _, err := client.Put(ctx, "before-stop", "ok"); err != nil {
+		t.Fatalf("unable to write to healthy cluster: %v", err)
+	}
+
+	// Stop a single node. With 3 nodes the remaining 2 still hold a
+	// majority, so the cluster should keep serving reads and writes.
+	const downNode = 1
+	if err := cluster.StopNode(downNode); err != nil {
+		t.Fatalf("unable to stop node: %v", err)
+	}
+
+	ctx2, cancel2 := context.WithTimeout(
+		context.Background(), readyTimeout,
+	)
+	defer cancel2()
+
+	if _, err := client.Put(ctx2, "while-down", "ok"); err != nil {
+		t.Fatalf("cluster did not survive loss of a minority node: %v",
+			err)
+	}
+
+	resp, err := client.Get(ctx2, "before-stop")
+	if err != nil {
+		t.Fatalf("unable to read from cluster after node loss: %v", err)
+	}
+	if len(resp.Kvs) != 1 || string(resp.Kvs[0].Value) != "ok" {
+		t.Fatalf("unexpected value for before-stop key: %+v", resp.Kvs)
+	}
+
+	// Bring the node back and confirm it rejoins and catches up on the
+	// key written while it was offline.
+	if err := cluster.RestartNode(downNode); err != nil {
+		t.Fatalf("unable to restart node: %v", err)
+	}
+
+	waitForCatchUp(t, cluster, downNode, "while-down")
+}
+
+// waitForCatchUp polls the restarted node directly until it reports the
+// given key, or fails the test once readyTimeout has elapsed.
+func waitForCatchUp(t *testing.T, cluster *EtcdCluster, idx int, key string) {
+	t.Helper()
+
+	node := cluster.nodes[idx]
+
+	nodeClient, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://" + node.clientURL},
+		DialTimeout: defaultDialTimeout,
+	})
+	if err != nil {
+		t.Fatalf("unable to create client for restarted node: %v", err)
+	}
+	defer nodeClient.Close()
+
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(
+			context.Background(), defaultDialTimeout,
+		)
+		resp, err := nodeClient.Get(ctx, key)
+		cancel()
+
+		if err == nil && len(resp.Kvs) == 1 {
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatalf("restarted node never caught up on key %q", key)
+}
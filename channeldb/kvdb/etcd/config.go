@@ -0,0 +1,112 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultMaxTxnOps is the maximum number of operations an etcd
+	// transaction (such as a channel backup commit) may contain on the
+	// embedded server. The client sets no limit of its own on this.
+	defaultMaxTxnOps = 8192
+
+	// defaultMaxMsgSize is the maximum size, in bytes, of a single etcd
+	// request or response, both for the embedded server and for the
+	// client. The client-side limits mirror the server-side
+	// MaxRequestBytes so that a large channel backup commit doesn't
+	// fail on the client side after already clearing the server.
+	defaultMaxMsgSize = 16384 * 1024
+
+	// defaultDialTimeout is the default time the client waits for the
+	// initial connection to the cluster to be established.
+	defaultDialTimeout = 3 * time.Second
+
+	// defaultKeepAliveTime is the default interval at which the client
+	// pings the server to check that the connection is still alive.
+	defaultKeepAliveTime = 10 * time.Second
+
+	// defaultKeepAliveTimeout is the default time the client waits for
+	// a keep-alive ping response before considering the connection
+	// dead.
+	defaultKeepAliveTimeout = 3 * time.Second
+
+	// defaultAutoSyncInterval is the default interval at which the
+	// client refreshes its view of the cluster membership.
+	defaultAutoSyncInterval = 30 * time.Second
+)
+
+// BackendConfig holds values necessary to connect to an etcd cluster or
+// embedded etcd instance used as a kvdb backend.
+type BackendConfig struct {
+	// Ctx is the context that is used to control the lifetime of the
+	// etcd client. Canceling it will cause the client (and any
+	// outstanding requests) to be torn down.
+	Ctx context.Context
+
+	// Host is the comma-separated list of client endpoints to connect
+	// to, e.g. "http://127.0.0.1:2379,http://127.0.0.1:2479".
+	Host string
+
+	// User is the username used for authentication.
+	User string
+
+	// Pass is the password used for authentication.
+	Pass string
+
+	// InsecureSkipVerify disables TLS certificate verification when
+	// connecting to Host over https. It is ignored when CertFile or
+	// CAFile is set.
+	InsecureSkipVerify bool
+
+	// CertFile is the path to the client certificate used for mutual
+	// TLS authentication against the cluster. Leave empty to disable
+	// client certificate authentication.
+	CertFile string
+
+	// KeyFile is the path to the private key matching CertFile.
+	KeyFile string
+
+	// CAFile is the path to the PEM-encoded CA certificate used to
+	// verify the server's certificate chain. Leave empty to fall back
+	// to the system trust store.
+	CAFile string
+
+	// ServerName, if set, overrides the server name used to verify the
+	// hostname on the server's certificate.
+	ServerName string
+
+	// DialTimeout is the time the client waits for the initial
+	// connection to the cluster to be established. If zero,
+	// defaultDialTimeout is used.
+	DialTimeout time.Duration
+
+	// DialKeepAliveTime is the interval at which the client pings the
+	// server to check that the connection is still alive. Without
+	// this, a dead TCP connection (e.g. across a leader failover) may
+	// not be noticed until the OS-level keep-alive fires, which can
+	// block channel state writes for minutes. If zero,
+	// defaultKeepAliveTime is used.
+	DialKeepAliveTime time.Duration
+
+	// DialKeepAliveTimeout is the time the client waits for a
+	// keep-alive ping response before considering the connection dead.
+	// If zero, defaultKeepAliveTimeout is used.
+	DialKeepAliveTimeout time.Duration
+
+	// AutoSyncInterval is the interval at which the client refreshes
+	// its view of the cluster membership. If zero,
+	// defaultAutoSyncInterval is used. A negative value disables
+	// auto-sync.
+	AutoSyncInterval time.Duration
+
+	// MaxCallSendMsgSize is the client-side limit on the size of a
+	// single request. If zero, defaultMaxMsgSize is used.
+	MaxCallSendMsgSize int
+
+	// MaxCallRecvMsgSize is the client-side limit on the size of a
+	// single response. If zero, defaultMaxMsgSize is used.
+	MaxCallRecvMsgSize int
+}
@@ -18,8 +18,10 @@ const (
 	readyTimeout = 10 * time.Second
 
 	// defaultEtcdPort is the start of the range for listening ports of
-	// embedded etcd servers. Ports are monotonically increasing starting
-	// from this number and are determined by the results of getFreePort().
+	// embedded etcd servers that need to know their port before
+	// starting (e.g. cluster peers listed in InitialCluster). Ports are
+	// monotonically increasing starting from this number and are
+	// determined by the results of getFreePort().
 	defaultEtcdPort = 2379
 )
 
@@ -30,8 +32,16 @@ var (
 )
 
 // getFreePort returns the first port that is available for listening by a new
-// embedded etcd server. It panics if no port is found and the maximum available
-// TCP port is reached.
+// embedded etcd server. It panics if no port is found and the maximum
+// available TCP port is reached.
+//
+// This is inherently racy: the port can be claimed by another process
+// between the check and embed.StartEtcd actually binding it. Callers that
+// don't need to know their port ahead of time (e.g. a single standalone
+// instance) should instead bind to port 0 and read back the address etcd
+// actually bound, as NewEmbeddedEtcdInstance does. getFreePort remains here
+// only for callers such as cluster peers that must advertise a fixed port
+// in InitialCluster before the process starts.
 func getFreePort() int {
 	port := atomic.AddUint32(&lastPort, 1)
 	for port < 65535 {
@@ -53,21 +63,26 @@ func getFreePort() int {
 }
 
 // NewEmbeddedEtcdInstance creates an embedded etcd instance for testing,
-// listening on random open ports. Returns the backend config and a cleanup
-// func that will stop the etcd instance.
+// listening on random open ports. Rather than pre-selecting a port and
+// racing embed.StartEtcd to bind it, it lets the kernel pick a free port
+// and reads back the address actually bound, which avoids flaky "address
+// already in use" failures under parallel test runs. Returns the backend
+// config and a cleanup func that will stop the etcd instance.
 func NewEmbeddedEtcdInstance(path string) (*BackendConfig, func(), error) {
 	cfg := embed.NewConfig()
 	cfg.Dir = path
 
 	// To ensure that we can submit large transactions.
-	cfg.MaxTxnOps = 8192
-	cfg.MaxRequestBytes = 16384 * 1024
+	cfg.MaxTxnOps = defaultMaxTxnOps
+	cfg.MaxRequestBytes = defaultMaxMsgSize
 
-	// Listen on random free ports.
-	clientURL := fmt.Sprintf("127.0.0.1:%d", getFreePort())
-	peerURL := fmt.Sprintf("127.0.0.1:%d", getFreePort())
-	cfg.LCUrls = []url.URL{{Host: clientURL}}
-	cfg.LPUrls = []url.URL{{Host: peerURL}}
+	// Let the kernel choose free ports for us, then read back the
+	// addresses actually bound once etcd is ready.
+	cfg.LCUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	cfg.LPUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	cfg.ACUrls = cfg.LCUrls
+	cfg.APUrls = cfg.LPUrls
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
 
 	etcd, err := embed.StartEtcd(cfg)
 	if err != nil {
@@ -82,11 +97,13 @@ func NewEmbeddedEtcdInstance(path string) (*BackendConfig, func(), error) {
 			fmt.Errorf("etcd failed to start after: %v", readyTimeout)
 	}
 
+	clientURL := etcd.Clients[0].Addr().String()
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	connConfig := &BackendConfig{
 		Ctx:                ctx,
-		Host:               "http://" + peerURL,
+		Host:               "http://" + clientURL,
 		User:               "user",
 		Pass:               "pass",
 		InsecureSkipVerify: true,
@@ -0,0 +1,264 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/etcd/embed"
+)
+
+// TLSTestConfig bundles up the certificate and key files needed to start an
+// embedded etcd instance with mutual TLS enabled.
+type TLSTestConfig struct {
+	// CAFile is the PEM-encoded CA certificate used to verify both the
+	// server and client certificates.
+	CAFile string
+
+	// ServerCertFile and ServerKeyFile are the embedded instance's own
+	// certificate and private key.
+	ServerCertFile string
+	ServerKeyFile  string
+
+	// ClientCertFile and ClientKeyFile are the certificate and key
+	// returned to the caller (via BackendConfig) for authenticating as
+	// a client against the embedded instance.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// NewEmbeddedEtcdInstanceTLS creates an embedded etcd instance for testing
+// with mutual TLS enabled on both the client and peer listeners, listening
+// on random open ports. If tlsCfg is nil, an ephemeral CA along with server
+// and client certificates are generated in a "tls" subdirectory of path so that
+// callers can opt in to TLS without managing certificates themselves.
+// Returns the backend config and a cleanup func that will stop the etcd
+// instance.
+func NewEmbeddedEtcdInstanceTLS(path string, tlsCfg *TLSTestConfig) (
+	*BackendConfig, func(), error) {
+
+	if tlsCfg == nil {
+		genCfg, err := genTLSTestConfig(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsCfg = genCfg
+	}
+
+	cfg := embed.NewConfig()
+	cfg.Dir = path
+
+	// To ensure that we can submit large transactions.
+	cfg.MaxTxnOps = defaultMaxTxnOps
+	cfg.MaxRequestBytes = defaultMaxMsgSize
+
+	// Let the kernel choose free ports for us, then read back the
+	// addresses actually bound once etcd is ready, avoiding the
+	// close-then-reopen TOCTOU race of pre-selecting a port.
+	cfg.LCUrls = []url.URL{{Scheme: "https", Host: "127.0.0.1:0"}}
+	cfg.LPUrls = []url.URL{{Scheme: "https", Host: "127.0.0.1:0"}}
+	cfg.ACUrls = cfg.LCUrls
+	cfg.APUrls = cfg.LPUrls
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	cfg.ClientTLSInfo.CAFile = tlsCfg.CAFile
+	cfg.ClientTLSInfo.CertFile = tlsCfg.ServerCertFile
+	cfg.ClientTLSInfo.KeyFile = tlsCfg.ServerKeyFile
+	cfg.ClientTLSInfo.ClientCertAuth = true
+
+	cfg.PeerTLSInfo.CAFile = tlsCfg.CAFile
+	cfg.PeerTLSInfo.CertFile = tlsCfg.ServerCertFile
+	cfg.PeerTLSInfo.KeyFile = tlsCfg.ServerKeyFile
+	cfg.PeerTLSInfo.ClientCertAuth = true
+
+	etcd, err := embed.StartEtcd(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(readyTimeout):
+		etcd.Close()
+		return nil, nil,
+			fmt.Errorf("etcd failed to start after: %v", readyTimeout)
+	}
+
+	clientURL := etcd.Clients[0].Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	connConfig := &BackendConfig{
+		Ctx:        ctx,
+		Host:       "https://" + clientURL,
+		CertFile:   tlsCfg.ClientCertFile,
+		KeyFile:    tlsCfg.ClientKeyFile,
+		CAFile:     tlsCfg.CAFile,
+		ServerName: "localhost",
+	}
+
+	return connConfig, func() {
+		cancel()
+		etcd.Close()
+	}, nil
+}
+
+// genTLSTestConfig generates an ephemeral CA along with a server and client
+// certificate signed by it, writing them as PEM files under a "tls"
+// subdirectory of dir. It is only meant for test use.
+func genTLSTestConfig(dir string) (*TLSTestConfig, error) {
+	tlsDir := filepath.Join(dir, "tls")
+	if err := os.MkdirAll(tlsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	caCert, caKey, err := genSelfSignedCert(
+		pkix.Name{CommonName: "kvdb-etcd-test-ca"}, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	caFile := filepath.Join(tlsDir, "ca.pem")
+	if err := writeCert(caFile, caCert); err != nil {
+		return nil, err
+	}
+
+	serverCertFile, serverKeyFile, err := genSignedCert(
+		tlsDir, "server", []string{"localhost"}, caCert, caKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCertFile, clientKeyFile, err := genSignedCert(
+		tlsDir, "client", nil, caCert, caKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TLSTestConfig{
+		CAFile:         caFile,
+		ServerCertFile: serverCertFile,
+		ServerKeyFile:  serverKeyFile,
+		ClientCertFile: clientCertFile,
+		ClientKeyFile:  clientKeyFile,
+	}, nil
+}
+
+// genSelfSignedCert generates a self-signed certificate and its private key
+// for the given subject, optionally valid for the given DNS names.
+func genSelfSignedCert(subject pkix.Name, dnsNames []string) (
+	*x509.Certificate, *ecdsa.PrivateKey, error) {
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &priv.PublicKey, priv,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, priv, nil
+}
+
+// genSignedCert generates a certificate/key pair signed by the given CA,
+// writes them as PEM files named "<name>-cert.pem"/"<name>-key.pem" under
+// dir, and returns their paths.
+func genSignedCert(dir, name string, dnsNames []string,
+	caCert *x509.Certificate, caKey *ecdsa.PrivateKey) (string, string, error) {
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth,
+		},
+		DNSNames: dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, caCert, &priv.PublicKey, caKey,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	certFile := filepath.Join(dir, name+"-cert.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: der,
+	}), 0600); err != nil {
+		return "", "", err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return "", "", err
+	}
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: keyBytes,
+	}), 0600); err != nil {
+		return "", "", err
+	}
+
+	return certFile, keyFile, nil
+}
+
+// writeCert PEM-encodes cert and writes it to path.
+func writeCert(path string, cert *x509.Certificate) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: cert.Raw,
+	}), 0600)
+}
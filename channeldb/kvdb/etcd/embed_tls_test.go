@@ -0,0 +1,70 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestEmbeddedEtcdInstanceTLSMutualAuth asserts that the mTLS gate
+// configured by NewEmbeddedEtcdInstanceTLS actually works end to end: a
+// client presenting the generated client certificate can complete the
+// handshake and talk to the instance, while a client that only trusts the
+// CA but presents no client certificate is rejected, since both
+// ClientTLSInfo and PeerTLSInfo are configured with ClientCertAuth enabled.
+func TestEmbeddedEtcdInstanceTLSMutualAuth(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "etcd-tls-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	backendConfig, cleanup, err := NewEmbeddedEtcdInstanceTLS(tempDir, nil)
+	if err != nil {
+		t.Fatalf("unable to start TLS-enabled etcd instance: %v", err)
+	}
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	// A client presenting the generated client certificate should be
+	// able to complete the mutual TLS handshake and use the instance.
+	authedClient, err := newEtcdClient(backendConfig)
+	if err != nil {
+		t.Fatalf("unable to create authenticated client: %v", err)
+	}
+	defer authedClient.Close()
+
+	if _, err := authedClient.Put(ctx, "mtls-test", "ok"); err != nil {
+		t.Fatalf("authenticated client could not write: %v", err)
+	}
+
+	// A client that trusts the CA but presents no client certificate
+	// should be rejected by the ClientCertAuth gate.
+	unauthedConfig := &BackendConfig{
+		Ctx:        context.Background(),
+		Host:       backendConfig.Host,
+		CAFile:     backendConfig.CAFile,
+		ServerName: backendConfig.ServerName,
+	}
+
+	unauthedClient, err := newEtcdClient(unauthedConfig)
+	if err != nil {
+		t.Fatalf("unable to create unauthenticated client: %v", err)
+	}
+	defer unauthedClient.Close()
+
+	ctx2, cancel2 := context.WithTimeout(
+		context.Background(), readyTimeout,
+	)
+	defer cancel2()
+
+	if _, err := unauthedClient.Get(ctx2, "mtls-test"); err == nil {
+		t.Fatalf("expected client without a client certificate to " +
+			"be rejected, but the request succeeded")
+	}
+}
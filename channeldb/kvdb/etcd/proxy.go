@@ -0,0 +1,75 @@
+// +build kvdb_etcd
+
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	pb "github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"github.com/coreos/etcd/proxy/grpcproxy"
+	"google.golang.org/grpc"
+)
+
+// NewEmbeddedEtcdProxy starts a local gRPC proxy that forwards all requests
+// to the cluster described by upstream, listening on a single stable local
+// endpoint. The upstream client is built with newEtcdClient, so the proxy
+// can forward to a cluster secured with mutual TLS and honors the same
+// dial-timeout/keep-alive/auto-sync tuning as any other BackendConfig. This
+// gives test code and sidecar deployments a single loopback endpoint to
+// connect to while the real cluster backing it is rotated, or partially
+// torn down, underneath - without having to reconfigure every client, and
+// without every client needing to know the upstream's TLS material. Returns
+// the backend config pointing at the proxy and a cleanup func that will
+// stop it.
+func NewEmbeddedEtcdProxy(upstream *BackendConfig) (*BackendConfig, func(), error) {
+	if upstream == nil || upstream.Host == "" {
+		return nil, nil, fmt.Errorf("at least one upstream etcd " +
+			"endpoint is required")
+	}
+
+	client, err := newEtcdClient(upstream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kvProxy, _ := grpcproxy.NewKVProxy(client)
+	watchProxy, _ := grpcproxy.NewWatchProxy(client)
+	leaseProxy, _ := grpcproxy.NewLeaseProxy(client)
+	clusterProxy, _ := grpcproxy.NewClusterProxy(client, "", "")
+	maintenanceProxy := grpcproxy.NewMaintenanceProxy(client)
+	authProxy := grpcproxy.NewAuthProxy(client)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterKVServer(grpcServer, kvProxy)
+	pb.RegisterWatchServer(grpcServer, watchProxy)
+	pb.RegisterLeaseServer(grpcServer, leaseProxy)
+	pb.RegisterClusterServer(grpcServer, clusterProxy)
+	pb.RegisterMaintenanceServer(grpcServer, maintenanceProxy)
+	pb.RegisterAuthServer(grpcServer, authProxy)
+
+	// Unlike a cluster peer, the proxy's address is never advertised to
+	// anyone before it binds, so there's no need to pre-select a port:
+	// let the kernel choose one and read back what was actually bound.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+
+	go grpcServer.Serve(lis)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	connConfig := &BackendConfig{
+		Ctx:  ctx,
+		Host: "http://" + lis.Addr().String(),
+	}
+
+	return connConfig, func() {
+		cancel()
+		grpcServer.Stop()
+		client.Close()
+	}, nil
+}
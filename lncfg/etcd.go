@@ -0,0 +1,35 @@
+package lncfg
+
+import "time"
+
+// EtcdConfig holds the operator-facing configuration for connecting lnd to
+// an external etcd cluster used as a channel.db backend.
+type EtcdConfig struct {
+	Host string `long:"host" description:"Host and port of the etcd instance, or a comma-separated list of endpoints to connect to multiple etcd cluster members."`
+
+	User string `long:"user" description:"Username for the etcd database."`
+
+	Pass string `long:"pass" description:"Password for the etcd database."`
+
+	CertFile string `long:"certfile" description:"Path to the client certificate used for mutual TLS authentication against the cluster."`
+
+	KeyFile string `long:"keyfile" description:"Path to the private key matching certfile."`
+
+	CAFile string `long:"cafile" description:"Path to the PEM-encoded CA certificate used to verify the cluster's certificate chain. Leave empty to fall back to the system trust store."`
+
+	ServerName string `long:"servername" description:"Server name override used to verify the hostname on the cluster's certificate."`
+
+	InsecureSkipVerify bool `long:"insecure_skip_verify" description:"Whether we intend to skip TLS verification."`
+
+	DialTimeout time.Duration `long:"dialtimeout" description:"Time the etcd client waits for the initial connection to the cluster to be established."`
+
+	DialKeepAliveTime time.Duration `long:"dialkeepalivetime" description:"Interval at which the etcd client pings the cluster to check that the connection is still alive. Without this, a dead connection across a leader failover may go unnoticed until the OS-level keep-alive fires, which can block channel state writes for minutes."`
+
+	DialKeepAliveTimeout time.Duration `long:"dialkeepalivetimeout" description:"Time the etcd client waits for a keep-alive ping response before considering the connection dead."`
+
+	AutoSyncInterval time.Duration `long:"autosyncinterval" description:"Interval at which the etcd client refreshes its view of the cluster membership. If zero, a default of 30s is used; a negative value disables auto-sync."`
+
+	MaxCallSendMsgSize int `long:"maxcallsendmsgsize" description:"Client-side limit, in bytes, on the size of a single etcd request. Should mirror the cluster's max-request-bytes so that a large channel backup commit doesn't fail on the client side."`
+
+	MaxCallRecvMsgSize int `long:"maxcallrecvmsgsize" description:"Client-side limit, in bytes, on the size of a single etcd response."`
+}
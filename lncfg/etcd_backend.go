@@ -0,0 +1,33 @@
+// +build kvdb_etcd
+
+package lncfg
+
+import (
+	"context"
+
+	"github.com/lightningnetwork/lnd/channeldb/kvdb/etcd"
+)
+
+// BackendConfig converts this EtcdConfig into the etcd.BackendConfig
+// consumed by the kvdb/etcd backend, so that every tuning knob an operator
+// sets on the etcd config section actually reaches the etcd client used by
+// a replicated lnd cluster.
+func (c *EtcdConfig) BackendConfig(ctx context.Context) *etcd.BackendConfig {
+	return &etcd.BackendConfig{
+		Ctx:                  ctx,
+		Host:                 c.Host,
+		User:                 c.User,
+		Pass:                 c.Pass,
+		CertFile:             c.CertFile,
+		KeyFile:              c.KeyFile,
+		CAFile:               c.CAFile,
+		ServerName:           c.ServerName,
+		InsecureSkipVerify:   c.InsecureSkipVerify,
+		DialTimeout:          c.DialTimeout,
+		DialKeepAliveTime:    c.DialKeepAliveTime,
+		DialKeepAliveTimeout: c.DialKeepAliveTimeout,
+		AutoSyncInterval:     c.AutoSyncInterval,
+		MaxCallSendMsgSize:   c.MaxCallSendMsgSize,
+		MaxCallRecvMsgSize:   c.MaxCallRecvMsgSize,
+	}
+}
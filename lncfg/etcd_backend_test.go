@@ -0,0 +1,65 @@
+// +build kvdb_etcd
+
+package lncfg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestEtcdConfigBackendConfig asserts that every tuning knob an operator
+// sets in the etcd config section is actually carried over to the
+// etcd.BackendConfig used to build the etcd client, since the kvdb/etcd
+// backend has no other way to learn about them.
+func TestEtcdConfigBackendConfig(t *testing.T) {
+	cfg := &EtcdConfig{
+		Host:                 "https://localhost:2379",
+		User:                 "user",
+		Pass:                 "pass",
+		CertFile:             "cert.pem",
+		KeyFile:              "key.pem",
+		CAFile:               "ca.pem",
+		ServerName:           "etcd.example.com",
+		InsecureSkipVerify:   true,
+		DialTimeout:          5 * time.Second,
+		DialKeepAliveTime:    15 * time.Second,
+		DialKeepAliveTimeout: 4 * time.Second,
+		AutoSyncInterval:     45 * time.Second,
+		MaxCallSendMsgSize:   1024,
+		MaxCallRecvMsgSize:   2048,
+	}
+
+	backendConfig := cfg.BackendConfig(context.Background())
+
+	switch {
+	case backendConfig.Host != cfg.Host:
+		t.Fatalf("Host not carried over")
+	case backendConfig.User != cfg.User:
+		t.Fatalf("User not carried over")
+	case backendConfig.Pass != cfg.Pass:
+		t.Fatalf("Pass not carried over")
+	case backendConfig.CertFile != cfg.CertFile:
+		t.Fatalf("CertFile not carried over")
+	case backendConfig.KeyFile != cfg.KeyFile:
+		t.Fatalf("KeyFile not carried over")
+	case backendConfig.CAFile != cfg.CAFile:
+		t.Fatalf("CAFile not carried over")
+	case backendConfig.ServerName != cfg.ServerName:
+		t.Fatalf("ServerName not carried over")
+	case backendConfig.InsecureSkipVerify != cfg.InsecureSkipVerify:
+		t.Fatalf("InsecureSkipVerify not carried over")
+	case backendConfig.DialTimeout != cfg.DialTimeout:
+		t.Fatalf("DialTimeout not carried over")
+	case backendConfig.DialKeepAliveTime != cfg.DialKeepAliveTime:
+		t.Fatalf("DialKeepAliveTime not carried over")
+	case backendConfig.DialKeepAliveTimeout != cfg.DialKeepAliveTimeout:
+		t.Fatalf("DialKeepAliveTimeout not carried over")
+	case backendConfig.AutoSyncInterval != cfg.AutoSyncInterval:
+		t.Fatalf("AutoSyncInterval not carried over")
+	case backendConfig.MaxCallSendMsgSize != cfg.MaxCallSendMsgSize:
+		t.Fatalf("MaxCallSendMsgSize not carried over")
+	case backendConfig.MaxCallRecvMsgSize != cfg.MaxCallRecvMsgSize:
+		t.Fatalf("MaxCallRecvMsgSize not carried over")
+	}
+}